@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"hash"
@@ -15,6 +16,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
 )
 
 // SecretBytes attempts to base64 decode the secret, if that fails it treats the secret as binary
@@ -38,48 +41,63 @@ func SecretBytes(secret string) []byte {
 // cookies are stored in a 3 part (value + timestamp + signature) to enforce that the values are as originally set.
 // additionally, the 'value' is encrypted so it's opaque to the browser
 
-// Validate ensures a cookie is properly signed
-func Validate(cookie *http.Cookie, seed string, expiration time.Duration) (value []byte, t time.Time, ok bool) {
+// Validate ensures a cookie is properly signed. seeds is tried in order so that
+// rotated HMAC keys (oldest last) continue to validate in-flight sessions.
+func Validate(cookie *http.Cookie, seeds []string, expiration time.Duration) (value []byte, t time.Time, err Error) {
+	if len(seeds) == 0 {
+		return nil, t, newUsageError("at least one seed is required")
+	}
+
 	// value, timestamp, sig
 	parts := strings.Split(cookie.Value, "|")
 	if len(parts) != 3 {
-		return
+		return nil, t, newDecodeError("cookie value should have 3 parts, got %d", len(parts))
 	}
-	if checkSignature(parts[2], seed, cookie.Name, parts[0], parts[1]) {
-		ts, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return
-		}
-		// The expiration timestamp set when the cookie was created
-		// isn't sent back by the browser. Hence, we check whether the
-		// creation timestamp stored in the cookie falls within the
-		// window defined by (Now()-expiration, Now()].
-		t = time.Unix(int64(ts), 0)
-		if t.After(time.Now().Add(expiration*-1)) && t.Before(time.Now().Add(time.Minute*5)) {
-			// it's a valid cookie. now get the contents
-			rawValue, err := base64.URLEncoding.DecodeString(parts[0])
-			if err == nil {
-				value = rawValue
-				ok = true
-				return
-			}
-		}
+
+	if err := checkSignature(parts[2], seeds, cookie.Name, parts[0], parts[1]); err != nil {
+		return nil, t, err
+	}
+
+	ts, convErr := strconv.Atoi(parts[1])
+	if convErr != nil {
+		return nil, t, newDecodeError("invalid timestamp %q: %s", parts[1], convErr)
+	}
+	// The expiration timestamp set when the cookie was created
+	// isn't sent back by the browser. Hence, we check whether the
+	// creation timestamp stored in the cookie falls within the
+	// window defined by (Now()-expiration, Now()].
+	t = time.Unix(int64(ts), 0)
+	if !t.After(time.Now().Add(expiration*-1)) || !t.Before(time.Now().Add(time.Minute*5)) {
+		return nil, t, newExpiredError("cookie is outside its valid time window")
 	}
-	return
+
+	// it's a valid cookie. now get the contents
+	rawValue, decErr := base64.URLEncoding.DecodeString(parts[0])
+	if decErr != nil {
+		return nil, t, newDecodeError("value is not valid base64: %s", decErr)
+	}
+	return rawValue, t, nil
 }
 
-// SignedValue returns a cookie that is signed and can later be checked with Validate
-func SignedValue(seed string, key string, value []byte, now time.Time) string {
+// SignedValue returns a cookie that is signed and can later be checked with Validate.
+// Signing always uses seeds[0] -- the current key -- so rotation only ever adds
+// trailing fallback keys for Validate, it never changes what new cookies are signed with.
+// Returns an empty string if no seed is configured, rather than panicking on seeds[0].
+func SignedValue(seeds []string, key string, value []byte, now time.Time) string {
+	if len(seeds) == 0 {
+		return ""
+	}
+
 	encodedValue := base64.URLEncoding.EncodeToString(value)
 	timeStr := fmt.Sprintf("%d", now.Unix())
-	sig := cookieSignature(sha256.New, seed, key, encodedValue, timeStr)
+	sig := cookieSignature(sha256.New, seeds[0], key, encodedValue, timeStr)
 	cookieVal := fmt.Sprintf("%s|%s|%s", encodedValue, timeStr, sig)
 	return cookieVal
 }
 
-func cookieSignature(signer func() hash.Hash, args ...string) string {
-	h := hmac.New(signer, []byte(args[0]))
-	for _, arg := range args[1:] {
+func cookieSignature(signer func() hash.Hash, seed string, args ...string) string {
+	h := hmac.New(signer, []byte(seed))
+	for _, arg := range args {
 		h.Write([]byte(arg))
 	}
 	var b []byte
@@ -87,26 +105,66 @@ func cookieSignature(signer func() hash.Hash, args ...string) string {
 	return base64.URLEncoding.EncodeToString(b)
 }
 
-func checkSignature(signature string, args ...string) bool {
-	checkSig := cookieSignature(sha256.New, args...)
-	if checkHmac(signature, checkSig) {
-		return true
-	}
+// checkSignature tries each seed in order -- current key first, then previous
+// keys -- so a cookie signed before a key rotation still validates. A decode
+// error (the supplied signature isn't valid base64) is the same for every
+// seed, so it short-circuits the loop instead of being masked by a generic
+// MAC-invalid error from the last seed tried.
+func checkSignature(signature string, seeds []string, args ...string) Error {
+	for _, seed := range seeds {
+		checkSig := cookieSignature(sha256.New, seed, args...)
+		ok, err := checkHmac(signature, checkSig)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
 
-	// TODO: After appropriate rollout window, remove support for SHA1
-	legacySig := cookieSignature(sha1.New, args...)
-	return checkHmac(signature, legacySig)
+		// TODO: After appropriate rollout window, remove support for SHA1
+		legacySig := cookieSignature(sha1.New, seed, args...)
+		ok, err = checkHmac(signature, legacySig)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return newMACError("signature does not match any configured key")
 }
 
-func checkHmac(input, expected string) bool {
-	inputMAC, err1 := base64.URLEncoding.DecodeString(input)
-	if err1 == nil {
-		expectedMAC, err2 := base64.URLEncoding.DecodeString(expected)
-		if err2 == nil {
-			return hmac.Equal(inputMAC, expectedMAC)
-		}
+// checkHmac compares input against expected. expected is always generated
+// internally by cookieSignature, so it's always valid base64; input comes
+// from the cookie and may not be. Decoding input up front and branching
+// immediately on a decode failure would let timing leak whether input was
+// malformed base64 or simply the wrong (but well-formed) signature, so a
+// failed decode is instead normalized to a same-length zero buffer and fed
+// through the same subtle.ConstantTimeCompare as a successful one -- the
+// comparison itself always runs, and takes the same time either way. As a
+// fast accept path, a constant-time comparison of the raw (still-encoded)
+// strings is tried first; it can only ever succeed for a correct signature,
+// so it has no bearing on the reject-path timing guarantee above.
+func checkHmac(input, expected string) (bool, Error) {
+	if len(input) == len(expected) && subtle.ConstantTimeCompare([]byte(input), []byte(expected)) == 1 {
+		return true, nil
+	}
+
+	expectedMAC, err := base64.URLEncoding.DecodeString(expected)
+	if err != nil {
+		return false, &cookieError{kind: kindInternal, msg: fmt.Sprintf("computed signature is not valid base64: %s", err)}
 	}
-	return false
+
+	inputMAC, decodeErr := base64.URLEncoding.DecodeString(input)
+	if decodeErr != nil {
+		inputMAC = make([]byte, len(expectedMAC))
+	}
+
+	equal := subtle.ConstantTimeCompare(inputMAC, expectedMAC) == 1
+	if decodeErr != nil {
+		return false, newDecodeError("signature is not valid base64: %s", decodeErr)
+	}
+	return equal, nil
 }
 
 // Cipher provides methods to encrypt and decrypt
@@ -150,6 +208,24 @@ func NewBase64Cipher(initCipher func([]byte) (Cipher, error), secret []byte) (Ci
 	return &Base64Cipher{Cipher: c}, nil
 }
 
+// CipherFromName resolves an operator-facing cipher name -- e.g. a `cipher:
+// secretbox` config value -- to the matching Cipher constructor, for use as
+// NewBase64Cipher's initCipher. This package doesn't own config parsing
+// itself (there is no session/cookie store config in this tree), so this is
+// the selection point such config would call into.
+func CipherFromName(name string) (func([]byte) (Cipher, error), error) {
+	switch name {
+	case "", "aes-cfb":
+		return NewCFBCipher, nil
+	case "aes-gcm":
+		return NewGCMCipher, nil
+	case "secretbox":
+		return NewSecretBoxCipher, nil
+	default:
+		return nil, fmt.Errorf("unknown cipher %q", name)
+	}
+}
+
 // Encrypt encrypts a value with AES CFB & base64 encodes it
 func (c *Base64Cipher) Encrypt(value []byte) ([]byte, error) {
 	encrypted, err := c.Cipher.Encrypt([]byte(value))
@@ -256,6 +332,115 @@ func (c *GCMCipher) Decrypt(ciphertext []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// Seeds merges a current HMAC seed with previous ones -- e.g. a
+// `cookie_secret` value and a `cookie_secret_previous` list -- into the
+// seed order Validate/SignedValue/RotatingCipher expect: current key first,
+// then previous keys as fallbacks. This is the concrete hook config parsing
+// would call into to wire key rotation through; this package has no
+// session/cookie store config of its own in this tree.
+func Seeds(current string, previous ...string) []string {
+	return append([]string{current}, previous...)
+}
+
+type RotatingCipher struct {
+	DefaultCipher
+	Ciphers      []Cipher
+	MultiKeySeed []string
+}
+
+// NewRotatingCipher returns a Cipher that encrypts with the first (current) cipher
+// in ciphers and, on decrypt, tries each cipher in order until one succeeds --
+// letting operators rotate cookie/session encryption keys without invalidating
+// in-flight sessions. seeds is the matching list of HMAC keys for checkSignature.
+func NewRotatingCipher(ciphers []Cipher, seeds []string) (Cipher, error) {
+	if len(ciphers) == 0 {
+		return nil, fmt.Errorf("at least one cipher is required")
+	}
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("at least one seed is required")
+	}
+	return &RotatingCipher{Ciphers: ciphers, MultiKeySeed: seeds}, nil
+}
+
+// Encrypt always uses the current (first) cipher
+func (c *RotatingCipher) Encrypt(value []byte) ([]byte, error) {
+	return c.Ciphers[0].Encrypt(value)
+}
+
+// Sign produces a signed cookie value with SignedValue, using MultiKeySeed so
+// HMAC key rotation is driven by the same config as cipher rotation.
+func (c *RotatingCipher) Sign(key string, value []byte, now time.Time) string {
+	return SignedValue(c.MultiKeySeed, key, value, now)
+}
+
+// ValidateSignature validates cookie with Validate, trying each seed in
+// MultiKeySeed -- current key first, then previous keys -- so a cookie
+// signed before a key rotation still validates.
+func (c *RotatingCipher) ValidateSignature(cookie *http.Cookie, expiration time.Duration) ([]byte, time.Time, Error) {
+	return Validate(cookie, c.MultiKeySeed, expiration)
+}
+
+// Decrypt tries each cipher in order, falling back to older keys after a rotation
+func (c *RotatingCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	var err error
+	for _, cipher := range c.Ciphers {
+		var plaintext []byte
+		plaintext, err = cipher.Decrypt(ciphertext)
+		if err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to decrypt with any rotated key: %s", err)
+}
+
+// secretboxKeySize is the key size required by NaCl secretbox (XSalsa20-Poly1305)
+const secretboxKeySize = 32
+
+// secretboxNonceSize is the size of the random nonce prepended to each ciphertext
+const secretboxNonceSize = 24
+
+type SecretBoxCipher struct {
+	DefaultCipher
+	secret *[secretboxKeySize]byte
+}
+
+// NewSecretBoxCipher returns a new Cipher encrypting with NaCl secretbox
+// (XSalsa20-Poly1305), an AEAD that doesn't depend on AES-NI hardware and
+// offers misuse-resistant nonce handling
+func NewSecretBoxCipher(secret []byte) (Cipher, error) {
+	if len(secret) != secretboxKeySize {
+		return nil, fmt.Errorf("secretbox secret must be %d bytes, got %d", secretboxKeySize, len(secret))
+	}
+	var key [secretboxKeySize]byte
+	copy(key[:], secret)
+	return &SecretBoxCipher{secret: &key}, nil
+}
+
+// Encrypt with NaCl secretbox, prepending a random 24 byte nonce to the sealed box
+func (c *SecretBoxCipher) Encrypt(value []byte) ([]byte, error) {
+	var nonce [secretboxNonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, fmt.Errorf("failed to create nonce %s", err)
+	}
+	return secretbox.Seal(nonce[:], value, &nonce, c.secret), nil
+}
+
+// Decrypt a NaCl secretbox ciphertext, reading the nonce off the front
+func (c *SecretBoxCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < secretboxNonceSize {
+		return nil, fmt.Errorf("encrypted value should be at least %d bytes, but is only %d bytes", secretboxNonceSize, len(ciphertext))
+	}
+
+	var nonce [secretboxNonceSize]byte
+	copy(nonce[:], ciphertext[:secretboxNonceSize])
+
+	plaintext, ok := secretbox.Open(nil, ciphertext[secretboxNonceSize:], &nonce, c.secret)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt secretbox value")
+	}
+	return plaintext, nil
+}
+
 // codecFunc is a function that takes a string and encodes/decodes it
 type codecFunc func([]byte) ([]byte, error)
 
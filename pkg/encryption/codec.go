@@ -0,0 +1,125 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMaxCookieLength is the default cap on a signed+encoded cookie value,
+// matching the ~4096 byte limit most browsers enforce per cookie.
+const DefaultMaxCookieLength = 4096
+
+// defaultMaxChunks bounds how many sibling cookies a single value may be
+// split into, so a pathologically large session can't fan out unbounded.
+const defaultMaxChunks = 5
+
+// ErrValueTooLong is returned when a signed+encoded value exceeds MaxLength
+// even after being split into MaxChunks sibling cookies.
+var ErrValueTooLong = errors.New("cookie value exceeds the maximum length even when chunked")
+
+// CookieCodec wraps SignedValue/Validate with a maximum encoded length,
+// transparently splitting values that would exceed it across numbered
+// sibling cookies (e.g. _oauth2_proxy_0, _oauth2_proxy_1, ...) and
+// reassembling them on read. This guards against the well known failure
+// mode where a large ID token or group list silently breaks a session
+// by overflowing a single browser cookie.
+//
+// Compression also lives here rather than in SignedValue/Validate: those stay
+// plain sign/verify primitives, and CookieCodec is where this package already
+// composes them with the size-guarding concerns (chunking, and now shrinking)
+// that operators configure per cookie.
+type CookieCodec struct {
+	Seeds       []string
+	MaxLength   int
+	MaxChunks   int
+	Compression Compression
+}
+
+// NewCookieCodec returns a CookieCodec with a sane default MaxLength when one isn't given
+func NewCookieCodec(seeds []string, maxLength int) *CookieCodec {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxCookieLength
+	}
+	return &CookieCodec{Seeds: seeds, MaxLength: maxLength, MaxChunks: defaultMaxChunks, Compression: CompressionNone}
+}
+
+// Encode compresses value per Compression, signs the result, and -- if the
+// signed value exceeds MaxLength -- splits it across numbered sibling
+// cookies named name_0, name_1, .... Returns ErrValueTooLong if the value
+// still doesn't fit within MaxChunks cookies.
+func (c *CookieCodec) Encode(name string, key string, value []byte, now time.Time) ([]*http.Cookie, error) {
+	compressed, err := compressValue(value, c.Compression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress value: %s", err)
+	}
+
+	signed := SignedValue(c.Seeds, key, compressed, now)
+	if len(signed) <= c.MaxLength {
+		return []*http.Cookie{{Name: name, Value: signed}}, nil
+	}
+
+	var chunks []string
+	for len(signed) > 0 {
+		end := c.MaxLength
+		if end > len(signed) {
+			end = len(signed)
+		}
+		chunks = append(chunks, signed[:end])
+		signed = signed[end:]
+	}
+	if len(chunks) > c.MaxChunks {
+		return nil, ErrValueTooLong
+	}
+
+	cookies := make([]*http.Cookie, len(chunks))
+	for i, chunk := range chunks {
+		cookies[i] = &http.Cookie{Name: fmt.Sprintf("%s_%d", name, i), Value: chunk}
+	}
+	return cookies, nil
+}
+
+// Decode reassembles the sibling cookies for name out of cookies -- either a
+// single cookie named name, or name_0, name_1, ... in order -- validates the
+// rejoined value as by Validate, and reverses the compression it was
+// encoded with.
+func (c *CookieCodec) Decode(name string, cookies []*http.Cookie, expiration time.Duration) (value []byte, t time.Time, err Error) {
+	var compressed []byte
+
+	if cookie := findCookie(cookies, name); cookie != nil {
+		compressed, t, err = Validate(cookie, c.Seeds, expiration)
+	} else {
+		var b strings.Builder
+		for i := 0; i < c.MaxChunks; i++ {
+			chunk := findCookie(cookies, fmt.Sprintf("%s_%d", name, i))
+			if chunk == nil {
+				break
+			}
+			b.WriteString(chunk.Value)
+		}
+		if b.Len() == 0 {
+			return nil, t, newDecodeError("no cookie named %q or %q_0 found", name, name)
+		}
+		compressed, t, err = Validate(&http.Cookie{Name: name, Value: b.String()}, c.Seeds, expiration)
+	}
+	if err != nil {
+		return nil, t, err
+	}
+
+	value, decErr := decompressValue(compressed, c.Compression)
+	if decErr != nil {
+		return nil, t, newDecodeError("failed to decompress value: %s", decErr)
+	}
+	return value, t, nil
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCookieCodecRoundTripSingleCookie(t *testing.T) {
+	codec := NewCookieCodec([]string{"seed"}, 0)
+
+	cookies, err := codec.Encode("_oauth2_proxy", "_oauth2_proxy", []byte("small value"), time.Now())
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if len(cookies) != 1 {
+		t.Fatalf("expected a single cookie for a small value, got %d", len(cookies))
+	}
+
+	value, _, err := codec.Decode("_oauth2_proxy", cookies, time.Hour)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(value, []byte("small value")) {
+		t.Fatalf("got %q, want %q", value, "small value")
+	}
+}
+
+func TestCookieCodecChunksLargeValues(t *testing.T) {
+	codec := NewCookieCodec([]string{"seed"}, 100)
+	codec.MaxChunks = 20
+
+	large := bytes.Repeat([]byte("x"), 1000)
+	cookies, err := codec.Encode("_oauth2_proxy", "_oauth2_proxy", large, time.Now())
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if len(cookies) <= 1 {
+		t.Fatalf("expected the value to be split across multiple cookies, got %d", len(cookies))
+	}
+	for _, c := range cookies {
+		if len(c.Value) > codec.MaxLength {
+			t.Fatalf("chunk %q exceeds MaxLength %d", c.Name, codec.MaxLength)
+		}
+	}
+
+	value, _, err := codec.Decode("_oauth2_proxy", cookies, time.Hour)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(value, large) {
+		t.Fatalf("reassembled value does not match original")
+	}
+}
+
+func TestCookieCodecErrValueTooLong(t *testing.T) {
+	codec := NewCookieCodec([]string{"seed"}, 10)
+	codec.MaxChunks = 2
+
+	huge := bytes.Repeat([]byte("x"), 1000)
+	if _, err := codec.Encode("_oauth2_proxy", "_oauth2_proxy", huge, time.Now()); !errors.Is(err, ErrValueTooLong) {
+		t.Fatalf("expected ErrValueTooLong, got %v", err)
+	}
+}
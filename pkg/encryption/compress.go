@@ -0,0 +1,139 @@
+package encryption
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects the algorithm CookieCodec uses to shrink a session
+// payload before it's encrypted, so that large OIDC claims (groups, roles)
+// are more likely to fit under the per-cookie size limit.
+type Compression string
+
+const (
+	CompressionNone  Compression = "none"
+	CompressionFlate Compression = "flate"
+	CompressionGzip  Compression = "gzip"
+	CompressionZstd  Compression = "zstd"
+)
+
+// compression tags are prepended to the plaintext as a single byte, but only
+// when compression is actually enabled -- see compressValue.
+const (
+	tagFlate byte = iota + 1
+	tagGzip
+	tagZstd
+)
+
+func compressionTag(compression Compression) (tag byte, ok bool) {
+	switch compression {
+	case CompressionFlate:
+		return tagFlate, true
+	case CompressionGzip:
+		return tagGzip, true
+	case CompressionZstd:
+		return tagZstd, true
+	default:
+		return 0, false
+	}
+}
+
+// compressValue compresses value with compression and prepends a tag byte
+// naming the algorithm used. When compression is CompressionNone (the
+// default), value is returned unchanged with no tag byte at all, so it's
+// byte-identical to a cookie written before compression support existed.
+// With compression enabled, decompressValue tells a legacy payload apart by
+// its first byte not being the configured tag.
+func compressValue(value []byte, compression Compression) ([]byte, error) {
+	tag, ok := compressionTag(compression)
+	if !ok {
+		return value, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(tag)
+
+	switch tag {
+	case tagFlate:
+		w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case tagGzip:
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case tagZstd:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressValue reverses compressValue. When compression is CompressionNone,
+// data is untagged and returned as-is. When compression is enabled but the
+// leading byte isn't the tag that names it, data is assumed to be a legacy
+// payload written before compression was turned on (or before this codec's
+// Compression setting last changed) and is likewise returned as-is, rather
+// than erroring -- so enabling or changing compression on a live deployment
+// doesn't invalidate cookies already in flight.
+func decompressValue(data []byte, compression Compression) ([]byte, error) {
+	wantTag, ok := compressionTag(compression)
+	if !ok {
+		return data, nil
+	}
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	tag, payload := data[0], data[1:]
+	if tag != wantTag {
+		return data, nil
+	}
+
+	switch tag {
+	case tagFlate:
+		r := flate.NewReader(bytes.NewReader(payload))
+		defer r.Close()
+		return io.ReadAll(r)
+	case tagGzip:
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case tagZstd:
+		r, err := zstd.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unknown compression tag %d", tag)
+	}
+}
@@ -0,0 +1,82 @@
+package encryption
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCompressValueRoundTrip(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionFlate, CompressionGzip, CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			value := bytes.Repeat([]byte("session-data"), 50)
+
+			compressed, err := compressValue(value, compression)
+			if err != nil {
+				t.Fatalf("compressValue: %v", err)
+			}
+
+			decompressed, err := decompressValue(compressed, compression)
+			if err != nil {
+				t.Fatalf("decompressValue: %v", err)
+			}
+			if !bytes.Equal(decompressed, value) {
+				t.Fatalf("round trip mismatch for %s", compression)
+			}
+		})
+	}
+}
+
+func TestCompressValueNoneIsByteIdentical(t *testing.T) {
+	value := []byte("some session value")
+	compressed, err := compressValue(value, CompressionNone)
+	if err != nil {
+		t.Fatalf("compressValue: %v", err)
+	}
+	if !bytes.Equal(compressed, value) {
+		t.Fatal("CompressionNone should not add a tag byte or otherwise alter the value")
+	}
+}
+
+// A cookie written by CookieCodec before Compression support existed has no
+// tag byte at all. Decoding it with the default (CompressionNone) codec must
+// return it unchanged rather than mistaking its leading byte for a tag.
+func TestCookieCodecDecodesPreCompressionCookie(t *testing.T) {
+	seeds := []string{"seed"}
+	legacyValue := SignedValue(seeds, "_oauth2_proxy", []byte("legacy session value"), time.Now())
+	cookie := &http.Cookie{Name: "_oauth2_proxy", Value: legacyValue}
+
+	codec := NewCookieCodec(seeds, 0)
+	value, _, err := codec.Decode(cookie.Name, []*http.Cookie{cookie}, time.Hour)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(value, []byte("legacy session value")) {
+		t.Fatalf("got %q, want %q", value, "legacy session value")
+	}
+}
+
+// Cookies encoded while Compression was CompressionNone must still decode
+// once the deployment turns compression on, rather than logging everyone out
+// as soon as the config flips.
+func TestCookieCodecDecodesUncompressedCookieAfterCompressionEnabled(t *testing.T) {
+	seeds := []string{"seed"}
+	writer := NewCookieCodec(seeds, 0)
+
+	cookies, err := writer.Encode("_oauth2_proxy", "_oauth2_proxy", []byte("legacy session value"), time.Now())
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	reader := NewCookieCodec(seeds, 0)
+	reader.Compression = CompressionFlate
+
+	value, _, err := reader.Decode("_oauth2_proxy", cookies, time.Hour)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(value, []byte("legacy session value")) {
+		t.Fatalf("got %q, want %q", value, "legacy session value")
+	}
+}
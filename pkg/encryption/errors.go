@@ -0,0 +1,63 @@
+package encryption
+
+import "fmt"
+
+// Error is returned by the cookie signing/validation functions in place of a
+// bare bool so callers (middleware, metrics) can react appropriately -- e.g.
+// log usage errors loudly but treat decode, expiry and MAC-invalid errors as
+// normal untrusted-input rejections, reserving IsInternal for genuine server
+// faults.
+type Error interface {
+	error
+
+	// IsUsage reports a caller mistake, such as a bad key length or a missing seed
+	IsUsage() bool
+	// IsDecode reports malformed untrusted input, such as bad base64 or the wrong segment count
+	IsDecode() bool
+	// IsExpired reports that the cookie was validly signed but fell outside its time window
+	IsExpired() bool
+	// IsMAC reports that the signature did not match any configured key -- a forged
+	// or stale cookie, and just as routine an untrusted-input rejection as IsDecode
+	IsMAC() bool
+	// IsInternal reports a genuine server-side fault, as opposed to untrusted input
+	IsInternal() bool
+}
+
+type errorKind int
+
+const (
+	kindUsage errorKind = iota
+	kindDecode
+	kindExpired
+	kindMAC
+	kindInternal
+)
+
+type cookieError struct {
+	kind errorKind
+	msg  string
+}
+
+func (e *cookieError) Error() string { return e.msg }
+
+func (e *cookieError) IsUsage() bool    { return e.kind == kindUsage }
+func (e *cookieError) IsDecode() bool   { return e.kind == kindDecode }
+func (e *cookieError) IsExpired() bool  { return e.kind == kindExpired }
+func (e *cookieError) IsMAC() bool      { return e.kind == kindMAC }
+func (e *cookieError) IsInternal() bool { return e.kind == kindInternal }
+
+func newUsageError(format string, args ...interface{}) Error {
+	return &cookieError{kind: kindUsage, msg: fmt.Sprintf(format, args...)}
+}
+
+func newDecodeError(format string, args ...interface{}) Error {
+	return &cookieError{kind: kindDecode, msg: fmt.Sprintf(format, args...)}
+}
+
+func newExpiredError(format string, args ...interface{}) Error {
+	return &cookieError{kind: kindExpired, msg: fmt.Sprintf(format, args...)}
+}
+
+func newMACError(format string, args ...interface{}) Error {
+	return &cookieError{kind: kindMAC, msg: fmt.Sprintf(format, args...)}
+}
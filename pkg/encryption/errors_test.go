@@ -0,0 +1,81 @@
+package encryption
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidateRejectsTamperedSignature(t *testing.T) {
+	seeds := []string{"seed"}
+	value := SignedValue(seeds, "_cookie", []byte("payload"), time.Now())
+	cookie := &http.Cookie{Name: "_cookie", Value: value + "x"}
+
+	_, _, err := Validate(cookie, seeds, time.Hour)
+	if err == nil {
+		t.Fatal("expected a tampered signature to fail validation")
+	}
+	if !err.IsDecode() {
+		t.Fatalf("expected a decode error for a malformed trailing signature, got %v", err)
+	}
+}
+
+func TestValidateRejectsWrongSignature(t *testing.T) {
+	seeds := []string{"seed"}
+	value := SignedValue([]string{"other-seed"}, "_cookie", []byte("payload"), time.Now())
+	cookie := &http.Cookie{Name: "_cookie", Value: value}
+
+	_, _, err := Validate(cookie, seeds, time.Hour)
+	if err == nil {
+		t.Fatal("expected a signature computed with a different seed to fail")
+	}
+	if !err.IsMAC() {
+		t.Fatalf("expected a MAC error, got %v", err)
+	}
+	if err.IsInternal() {
+		t.Fatal("a forged/stale signature is a routine rejection, not an internal error")
+	}
+}
+
+func TestValidateRejectsExpiredCookie(t *testing.T) {
+	seeds := []string{"seed"}
+	value := SignedValue(seeds, "_cookie", []byte("payload"), time.Now().Add(-time.Hour))
+	cookie := &http.Cookie{Name: "_cookie", Value: value}
+
+	_, _, err := Validate(cookie, seeds, time.Minute)
+	if err == nil {
+		t.Fatal("expected an expired cookie to fail validation")
+	}
+	if !err.IsExpired() {
+		t.Fatalf("expected an expiry error, got %v", err)
+	}
+	if err.IsMAC() || err.IsInternal() {
+		t.Fatalf("cookie expiry is routine, not a MAC or internal error: %v", err)
+	}
+}
+
+func TestValidateRejectsMalformedCookie(t *testing.T) {
+	cookie := &http.Cookie{Name: "_cookie", Value: "not-three-parts"}
+	_, _, err := Validate(cookie, []string{"seed"}, time.Hour)
+	if err == nil || !err.IsDecode() {
+		t.Fatalf("expected a decode error for a malformed cookie value, got %v", err)
+	}
+}
+
+func TestValidateRejectsNoSeeds(t *testing.T) {
+	cookie := &http.Cookie{Name: "_cookie", Value: "a|b|c"}
+	_, _, err := Validate(cookie, nil, time.Hour)
+	if err == nil || !err.IsUsage() {
+		t.Fatalf("expected a usage error for no configured seeds, got %v", err)
+	}
+}
+
+func TestCheckHmacFastPathAcceptsEqualStrings(t *testing.T) {
+	ok, err := checkHmac("not-valid-base64!!", "not-valid-base64!!")
+	if err != nil {
+		t.Fatalf("expected the constant-time fast path to accept identical strings without decoding, got error: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected identical strings to compare equal")
+	}
+}
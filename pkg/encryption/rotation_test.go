@@ -0,0 +1,113 @@
+package encryption
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidateFallsBackToPreviousSeed(t *testing.T) {
+	seeds := []string{"current-seed", "previous-seed"}
+	value := SignedValue(seeds[1:], "_cookie", []byte("payload"), time.Now())
+	cookie := &http.Cookie{Name: "_cookie", Value: value}
+
+	got, _, err := Validate(cookie, seeds, time.Hour)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !bytes.Equal(got, []byte("payload")) {
+		t.Fatalf("got %q, want %q", got, "payload")
+	}
+}
+
+func TestValidateRejectsUnknownSeed(t *testing.T) {
+	value := SignedValue([]string{"not-configured"}, "_cookie", []byte("payload"), time.Now())
+	cookie := &http.Cookie{Name: "_cookie", Value: value}
+
+	_, _, err := Validate(cookie, []string{"current-seed", "previous-seed"}, time.Hour)
+	if err == nil {
+		t.Fatal("expected validation to fail for a seed that was rotated out")
+	}
+	if !err.IsMAC() {
+		t.Fatalf("expected a MAC error, got %v (kind not MAC)", err)
+	}
+}
+
+func TestSignedValueEmptySeedsReturnsEmptyString(t *testing.T) {
+	if got := SignedValue(nil, "_cookie", []byte("payload"), time.Now()); got != "" {
+		t.Fatalf("expected empty string for no seeds, got %q", got)
+	}
+}
+
+func TestNewRotatingCipherRejectsEmptySeeds(t *testing.T) {
+	cipher, err := NewGCMCipher(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("NewGCMCipher: %v", err)
+	}
+	if _, err := NewRotatingCipher([]Cipher{cipher}, nil); err == nil {
+		t.Fatal("expected an error for no configured seeds")
+	}
+}
+
+func TestRotatingCipherDecryptsWithOlderKey(t *testing.T) {
+	oldCipher, err := NewGCMCipher(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("NewGCMCipher: %v", err)
+	}
+	newCipher, err := NewGCMCipher(bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatalf("NewGCMCipher: %v", err)
+	}
+
+	rotated, err := NewRotatingCipher([]Cipher{newCipher, oldCipher}, Seeds("current", "previous"))
+	if err != nil {
+		t.Fatalf("NewRotatingCipher: %v", err)
+	}
+
+	// Encrypted under the now-rotated-out old key, should still decrypt
+	ciphertext, err := oldCipher.Encrypt([]byte("payload"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	plaintext, err := rotated.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if !bytes.Equal(plaintext, []byte("payload")) {
+		t.Fatalf("got %q, want %q", plaintext, "payload")
+	}
+}
+
+func TestRotatingCipherValidateSignatureUsesMultiKeySeed(t *testing.T) {
+	cipher, err := NewGCMCipher(bytes.Repeat([]byte{3}, 32))
+	if err != nil {
+		t.Fatalf("NewGCMCipher: %v", err)
+	}
+	rotated := &RotatingCipher{Ciphers: []Cipher{cipher}, MultiKeySeed: Seeds("current", "previous")}
+
+	value := rotated.Sign("_cookie", []byte("payload"), time.Now())
+	cookie := &http.Cookie{Name: "_cookie", Value: value}
+
+	got, _, err2 := rotated.ValidateSignature(cookie, time.Hour)
+	if err2 != nil {
+		t.Fatalf("ValidateSignature: %v", err2)
+	}
+	if !bytes.Equal(got, []byte("payload")) {
+		t.Fatalf("got %q, want %q", got, "payload")
+	}
+}
+
+func TestSeeds(t *testing.T) {
+	got := Seeds("current", "p1", "p2")
+	want := []string{"current", "p1", "p2"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewSecretBoxCipherRejectsBadKeyLength(t *testing.T) {
+	if _, err := NewSecretBoxCipher(make([]byte, 16)); err == nil {
+		t.Fatal("expected an error for a 16 byte key, got nil")
+	}
+}
+
+func TestSecretBoxCipherRoundTrip(t *testing.T) {
+	key := make([]byte, secretboxKeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	c, err := NewSecretBoxCipher(key)
+	if err != nil {
+		t.Fatalf("NewSecretBoxCipher: %v", err)
+	}
+
+	plaintext := []byte("some session value")
+	ciphertext, err := c.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	decrypted, err := c.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("round trip mismatch: got %q, want %q", decrypted, plaintext)
+	}
+}
+
+func TestSecretBoxCipherDecryptWithWrongKeyFails(t *testing.T) {
+	key1 := bytes.Repeat([]byte{1}, secretboxKeySize)
+	key2 := bytes.Repeat([]byte{2}, secretboxKeySize)
+
+	c1, err := NewSecretBoxCipher(key1)
+	if err != nil {
+		t.Fatalf("NewSecretBoxCipher: %v", err)
+	}
+	c2, err := NewSecretBoxCipher(key2)
+	if err != nil {
+		t.Fatalf("NewSecretBoxCipher: %v", err)
+	}
+
+	ciphertext, err := c1.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	if _, err := c2.Decrypt(ciphertext); err == nil {
+		t.Fatal("expected decryption with the wrong key to fail")
+	}
+}
@@ -0,0 +1,72 @@
+package encryption
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+type testSession struct {
+	Email  string
+	Groups []string
+}
+
+func TestSerializersRoundTrip(t *testing.T) {
+	serializers := map[string]Serializer{
+		"json":    JSONSerializer{},
+		"gob":     GobSerializer{},
+		"msgpack": MsgPackSerializer{},
+	}
+
+	for name, s := range serializers {
+		t.Run(name, func(t *testing.T) {
+			in := testSession{Email: "user@example.com", Groups: []string{"a", "b"}}
+
+			data, err := s.Serialize(in)
+			if err != nil {
+				t.Fatalf("Serialize: %v", err)
+			}
+
+			var out testSession
+			if err := s.Deserialize(data, &out); err != nil {
+				t.Fatalf("Deserialize: %v", err)
+			}
+			if out.Email != in.Email || len(out.Groups) != len(in.Groups) {
+				t.Fatalf("got %+v, want %+v", out, in)
+			}
+		})
+	}
+}
+
+func TestSignedSerializedValueRoundTrip(t *testing.T) {
+	seeds := []string{"seed"}
+	in := testSession{Email: "user@example.com", Groups: []string{"a", "b"}}
+
+	value, err := SignedSerializedValue(seeds, "_cookie", JSONSerializer{}, in, time.Now())
+	if err != nil {
+		t.Fatalf("SignedSerializedValue: %v", err)
+	}
+
+	cookie := &http.Cookie{Name: "_cookie", Value: value}
+	var out testSession
+	if _, err := ValidateAndDeserialize(cookie, seeds, time.Hour, JSONSerializer{}, &out); err != nil {
+		t.Fatalf("ValidateAndDeserialize: %v", err)
+	}
+	if out.Email != in.Email {
+		t.Fatalf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestValidateAndDeserializeRejectsTamperedCookie(t *testing.T) {
+	seeds := []string{"seed"}
+	value, err := SignedSerializedValue(seeds, "_cookie", JSONSerializer{}, testSession{Email: "user@example.com"}, time.Now())
+	if err != nil {
+		t.Fatalf("SignedSerializedValue: %v", err)
+	}
+
+	cookie := &http.Cookie{Name: "_cookie", Value: value + "tampered"}
+	var out testSession
+	if _, err := ValidateAndDeserialize(cookie, seeds, time.Hour, JSONSerializer{}, &out); err == nil {
+		t.Fatal("expected a tampered cookie to fail validation")
+	}
+}